@@ -0,0 +1,244 @@
+// Package modbusclient provides modbus Serial Line/RTU and TCP/IP access
+// for client (master) applications to communicate with server (slave)
+// devices. Logic specifically in this file implements the Serial Line/ASCII
+// transmission mode: frames start with ':', carry hex-encoded data, end
+// with "\r\n", and are protected by an 8-bit LRC rather than a CRC-16.
+package modbusclient
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/tarm/serial"
+	"log"
+	"time"
+)
+
+const (
+	asciiStart = ':'
+	asciiEndCR = '\r'
+	asciiEndLF = '\n'
+)
+
+type ASCIIConfig struct {
+	Serial       serial.Config
+	SlaveAddress byte
+	Timeout      time.Duration
+	Debug        bool
+}
+
+type ASCIIContext struct {
+	*serial.Port
+	ASCIIConfig
+}
+
+// lrc computes and returns the 8-bit longitudinal redundancy check of
+// the given byte array, as used by the modbus ASCII transmission mode
+// in place of RTU's CRC-16.
+func lrc(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return byte(-int8(sum))
+}
+
+// GenerateASCIIFrame is a method corresponding to a RTUFrame object
+// which returns a byte array representing the associated modbus ASCII
+// application data unit (ADU): a ':' start character, the hex-encoded
+// PDU and LRC, and a trailing "\r\n".
+func (frame *RTUFrame) GenerateASCIIFrame() []byte {
+	pdu := frame.buildPDU()
+
+	body := make([]byte, len(pdu)+1)
+	copy(body, pdu)
+	body[len(pdu)] = lrc(pdu)
+
+	adu := make([]byte, 0, 1+len(body)*2+2)
+	adu = append(adu, asciiStart)
+	adu = append(adu, []byte(hex.EncodeToString(body))...)
+	adu = append(adu, asciiEndCR, asciiEndLF)
+	return adu
+}
+
+// ConnectASCII attempts to access the Serial Device for subsequent
+// ASCII writes and response reads from the modbus slave device
+func ConnectASCII(cfg ASCIIConfig) (*ASCIIContext, error) {
+	if cfg.Serial.ReadTimeout == 0 {
+		cfg.Serial.ReadTimeout = InterCharacterTimeout(cfg.Serial.Baud)
+	}
+	port, err := serial.OpenPort(&cfg.Serial)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := ASCIIContext{
+		Port:        port,
+		ASCIIConfig: cfg,
+	}
+	return &ctx, nil
+}
+
+// DisconnectASCII closes the underlying Serial Device connection
+func DisconnectASCII(ctx *ASCIIContext) {
+	ctx.Close()
+}
+
+// viaASCII is a private method which applies the given function
+// validator, to make sure the functionCode passed is valid for the
+// operation desired. If correct, it creates an RTUFrame given the
+// corresponding information, transmits the equivalent ASCII ADU to the
+// modbus server (slave device) specified by the given serial connection,
+// and returns a byte array of the slave device's decoded reply (slave
+// address, function code, data, and trailing LRC byte), and error (if any)
+func (a *ASCIIContext) viaASCII(fnValidator func(byte) bool, functionCode byte, startRegister, numRegisters uint16, data []byte) ([]byte, error) {
+	if !fnValidator(functionCode) {
+		return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION]
+	}
+
+	frame := new(RTUFrame)
+	frame.SlaveAddress = a.SlaveAddress
+	frame.FunctionCode = functionCode
+	frame.StartRegister = startRegister
+	frame.NumberOfRegisters = numRegisters
+	if len(data) > 0 {
+		frame.Data = data
+	}
+
+	adu := frame.GenerateASCIIFrame()
+	if a.Debug {
+		log.Println(fmt.Sprintf("Tx: %s", adu))
+	}
+
+	_, werr := a.Write(adu)
+	if werr != nil {
+		if a.Debug {
+			log.Println(fmt.Sprintf("ASCII Write Err: %s", werr))
+		}
+		return []byte{}, werr
+	}
+
+	return a.awaitASCIIResponse(frame)
+}
+
+// awaitASCIIResponse reads and decodes a ':'-delimited ASCII frame from
+// the serial device, discarding any frame addressed to another slave,
+// until a correctly-addressed reply has been received or a.Timeout has
+// elapsed since the request was sent.
+func (a *ASCIIContext) awaitASCIIResponse(frame *RTUFrame) ([]byte, error) {
+	deadline := time.Now().Add(a.Timeout)
+	var buf []byte
+
+	for {
+		if time.Now().After(deadline) {
+			if a.Debug {
+				log.Println("ASCII Read Timeout")
+			}
+			return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_UNSPECIFIED]
+		}
+
+		chunk := make([]byte, RTU_FRAME_MAXSIZE)
+		n, rerr := a.Read(chunk)
+		if rerr != nil {
+			if a.Debug {
+				log.Println(fmt.Sprintf("ASCII Read Err: %s", rerr))
+			}
+			return []byte{}, rerr
+		}
+		buf = append(buf, chunk[:n]...)
+
+		asciiFrame, rest, ok := extractASCIIFrame(buf)
+		if !ok {
+			continue
+		}
+		buf = rest
+
+		body, decErr := decodeASCIIFrame(asciiFrame)
+		if decErr != nil {
+			if a.Debug {
+				log.Println(fmt.Sprintf("ASCII Response Invalid: %s", decErr))
+			}
+			continue
+		}
+
+		if body[0] != frame.SlaveAddress {
+			if a.Debug {
+				log.Println(fmt.Sprintf("ASCII Response Discarded (unexpected slave %d): %x", body[0], body))
+			}
+			continue
+		}
+
+		if body[1] != frame.FunctionCode {
+			if (body[1] & 0x7f) == frame.FunctionCode {
+				switch body[2] {
+				case EXCEPTION_ILLEGAL_FUNCTION:
+					return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION]
+				case EXCEPTION_DATA_ADDRESS:
+					return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_DATA_ADDRESS]
+				case EXCEPTION_DATA_VALUE:
+					return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_DATA_VALUE]
+				case EXCEPTION_SLAVE_DEVICE_FAILURE:
+					return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_SLAVE_DEVICE_FAILURE]
+				}
+			}
+			return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_UNSPECIFIED]
+		}
+
+		// returned as-is, trailing LRC included, matching
+		// awaitRTUResponse's success return of the full frame with
+		// its trailing CRC still attached
+		return body, nil
+	}
+}
+
+// extractASCIIFrame scans buf for a complete ':'..."\r\n" ASCII frame,
+// returning its contents (without the start/end markers) and the
+// remainder of buf following it. It returns false if buf does not yet
+// contain a complete frame.
+func extractASCIIFrame(buf []byte) (frame []byte, rest []byte, ok bool) {
+	start := -1
+	for i, b := range buf {
+		if b == asciiStart {
+			start = i
+			break
+		}
+	}
+	if start == -1 {
+		return nil, buf, false
+	}
+
+	for i := start + 1; i+1 < len(buf); i++ {
+		if buf[i] == asciiEndCR && buf[i+1] == asciiEndLF {
+			return buf[start+1 : i], buf[i+2:], true
+		}
+	}
+	return nil, buf[start:], false
+}
+
+// decodeASCIIFrame hex-decodes an ASCII frame's body (as returned by
+// extractASCIIFrame) and verifies its trailing LRC, returning the
+// address/function/data bytes followed by the LRC byte.
+func decodeASCIIFrame(asciiFrame []byte) ([]byte, error) {
+	body, err := hex.DecodeString(string(asciiFrame))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) < 3 {
+		return nil, fmt.Errorf("modbus ASCII frame too short")
+	}
+	if body[len(body)-1] != lrc(body[:len(body)-1]) {
+		return nil, fmt.Errorf("modbus ASCII frame LRC mismatch")
+	}
+	return body, nil
+}
+
+// ASCIIRead performs the given modbus Read function over ASCII to the
+// given serialDevice, using the given frame data
+func (a *ASCIIContext) ASCIIRead(functionCode byte, startRegister, numRegisters uint16) ([]byte, error) {
+	return a.viaASCII(ValidReadFunction, functionCode, startRegister, numRegisters, []byte{})
+}
+
+// ASCIIWrite performs the given modbus Write function over ASCII to the
+// given serialDevice, using the given frame data
+func (a *ASCIIContext) ASCIIWrite(functionCode byte, startRegister, numRegisters uint16, data []byte) ([]byte, error) {
+	return a.viaASCII(ValidWriteFunction, functionCode, startRegister, numRegisters, data)
+}
@@ -0,0 +1,50 @@
+package modbusclient
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLRCKnownVector(t *testing.T) {
+	// read holding registers request: slave 1, start 0, quantity 10
+	request := []byte{0x01, FUNCTION_READ_HOLDING_REGISTERS, 0x00, 0x00, 0x00, 0x0a}
+	if got := lrc(request); got != 0xf2 {
+		t.Errorf("lrc() = %#x, want 0xf2", got)
+	}
+}
+
+func TestGenerateASCIIFrameRoundTrip(t *testing.T) {
+	frame := &RTUFrame{
+		SlaveAddress:      0x01,
+		FunctionCode:      FUNCTION_READ_HOLDING_REGISTERS,
+		StartRegister:     0,
+		NumberOfRegisters: 10,
+	}
+
+	adu := frame.GenerateASCIIFrame()
+	if adu[0] != asciiStart {
+		t.Fatalf("GenerateASCIIFrame() = %q, want leading ':'", adu)
+	}
+	if !bytes.HasSuffix(adu, []byte{asciiEndCR, asciiEndLF}) {
+		t.Fatalf("GenerateASCIIFrame() = %q, want trailing CRLF", adu)
+	}
+
+	asciiBody, rest, ok := extractASCIIFrame(adu)
+	if !ok || len(rest) != 0 {
+		t.Fatalf("extractASCIIFrame(%q) = (_, %q, %v), want the whole frame consumed", adu, rest, ok)
+	}
+
+	body, err := decodeASCIIFrame(asciiBody)
+	if err != nil {
+		t.Fatalf("decodeASCIIFrame() error = %v", err)
+	}
+	if body[0] != frame.SlaveAddress || body[1] != frame.FunctionCode {
+		t.Errorf("decoded body = %x, want address %#x function %#x", body, frame.SlaveAddress, frame.FunctionCode)
+	}
+}
+
+func TestDecodeASCIIFrameRejectsBadLRC(t *testing.T) {
+	if _, err := decodeASCIIFrame([]byte("0103000000")); err == nil {
+		t.Error("decodeASCIIFrame() with a mismatched LRC byte should have failed")
+	}
+}
@@ -0,0 +1,213 @@
+// Package modbusclient provides modbus Serial Line/RTU and TCP/IP access
+// for client (master) applications to communicate with server (slave)
+// devices. Logic in this file implements the server (slave) side request
+// dispatch shared by the RTU and TCP transports.
+package modbusclient
+
+// ProtocolHandler is implemented by applications that want this module to
+// act as a modbus server (slave). ServeRTU decodes each inbound ADU,
+// invokes the matching callback below, and frames
+// whatever the callback returns (or the error it returns) back to the
+// master as a response PDU, including the correct modbus exception when
+// a callback fails.
+type ProtocolHandler interface {
+	ReadCoils(startRegister, numRegisters uint16) ([]byte, error)
+	ReadDiscreteInputs(startRegister, numRegisters uint16) ([]byte, error)
+	ReadHoldingRegisters(startRegister, numRegisters uint16) ([]byte, error)
+	ReadInputRegisters(startRegister, numRegisters uint16) ([]byte, error)
+	WriteSingleCoil(register, value uint16) error
+	WriteSingleRegister(register, value uint16) error
+	WriteMultipleCoils(startRegister, numRegisters uint16, data []byte) error
+	WriteMultipleRegisters(startRegister, numRegisters uint16, data []byte) error
+
+	// OnError is called whenever dispatch fails to decode a request or a
+	// callback above returns an error, so the application can log it.
+	// It does not influence the response already framed for the master.
+	OnError(err error)
+}
+
+// dispatchRequest decodes the given request PDU (everything after the
+// slave address), invokes the matching ProtocolHandler callback, and
+// returns the response PDU (functionCode followed by its payload) ready
+// to be wrapped in a transport-specific ADU by the caller. On failure it
+// returns a PDU representing the correct modbus exception response.
+func dispatchRequest(handler ProtocolHandler, requestPDU []byte) []byte {
+	if len(requestPDU) < 1 {
+		handler.OnError(MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION])
+		return exceptionPDU(0, EXCEPTION_ILLEGAL_FUNCTION)
+	}
+
+	functionCode := requestPDU[0]
+	data := requestPDU[1:]
+
+	respData, err := dispatchFunction(handler, functionCode, data)
+	if err != nil {
+		handler.OnError(err)
+		return exceptionPDU(functionCode, exceptionCodeFor(err))
+	}
+
+	pdu := make([]byte, 1+len(respData))
+	pdu[0] = functionCode
+	copy(pdu[1:], respData)
+	return pdu
+}
+
+// dispatchFunction decodes the request data for the given function code
+// and calls the matching ProtocolHandler callback.
+func dispatchFunction(handler ProtocolHandler, functionCode byte, data []byte) ([]byte, error) {
+	switch functionCode {
+	case FUNCTION_READ_COILS:
+		startRegister, numRegisters, err := decodeReadRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		coils, err := handler.ReadCoils(startRegister, numRegisters)
+		if err != nil {
+			return nil, err
+		}
+		return encodeReadResponse(coils), nil
+
+	case FUNCTION_READ_DISCRETE_INPUTS:
+		startRegister, numRegisters, err := decodeReadRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		inputs, err := handler.ReadDiscreteInputs(startRegister, numRegisters)
+		if err != nil {
+			return nil, err
+		}
+		return encodeReadResponse(inputs), nil
+
+	case FUNCTION_READ_HOLDING_REGISTERS:
+		startRegister, numRegisters, err := decodeReadRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		registers, err := handler.ReadHoldingRegisters(startRegister, numRegisters)
+		if err != nil {
+			return nil, err
+		}
+		return encodeReadResponse(registers), nil
+
+	case FUNCTION_READ_INPUT_REGISTERS:
+		startRegister, numRegisters, err := decodeReadRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		registers, err := handler.ReadInputRegisters(startRegister, numRegisters)
+		if err != nil {
+			return nil, err
+		}
+		return encodeReadResponse(registers), nil
+
+	case FUNCTION_WRITE_SINGLE_COIL:
+		register, value, err := decodeSingleWriteRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := handler.WriteSingleCoil(register, value); err != nil {
+			return nil, err
+		}
+		return data[:4], nil
+
+	case FUNCTION_WRITE_SINGLE_REGISTER:
+		register, value, err := decodeSingleWriteRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := handler.WriteSingleRegister(register, value); err != nil {
+			return nil, err
+		}
+		return data[:4], nil
+
+	case FUNCTION_WRITE_MULTIPLE_COILS:
+		startRegister, numRegisters, payload, err := decodeMultipleWriteRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := handler.WriteMultipleCoils(startRegister, numRegisters, payload); err != nil {
+			return nil, err
+		}
+		return data[:4], nil
+
+	case FUNCTION_WRITE_MULTIPLE_REGISTERS:
+		startRegister, numRegisters, payload, err := decodeMultipleWriteRequest(data)
+		if err != nil {
+			return nil, err
+		}
+		if err := handler.WriteMultipleRegisters(startRegister, numRegisters, payload); err != nil {
+			return nil, err
+		}
+		return data[:4], nil
+	}
+
+	return nil, MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION]
+}
+
+// decodeReadRequest parses the starting register and quantity out of a
+// read-function request PDU body.
+func decodeReadRequest(data []byte) (startRegister, numRegisters uint16, err error) {
+	if len(data) < 4 {
+		return 0, 0, MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION]
+	}
+	startRegister = uint16(data[0])<<8 | uint16(data[1])
+	numRegisters = uint16(data[2])<<8 | uint16(data[3])
+	return startRegister, numRegisters, nil
+}
+
+// decodeSingleWriteRequest parses the target register and output value
+// out of a write-single-coil/register request PDU body. A request this
+// short still named a valid function code, so a malformed body is a
+// data-value problem, not an illegal-function one.
+func decodeSingleWriteRequest(data []byte) (register, value uint16, err error) {
+	if len(data) < 4 {
+		return 0, 0, MODBUS_EXCEPTIONS[EXCEPTION_DATA_VALUE]
+	}
+	register = uint16(data[0])<<8 | uint16(data[1])
+	value = uint16(data[2])<<8 | uint16(data[3])
+	return register, value, nil
+}
+
+// decodeMultipleWriteRequest parses the starting register, quantity, and
+// payload out of a write-multiple-coils/registers request PDU body. As
+// in decodeSingleWriteRequest, a short or truncated body is a data-value
+// problem, not an illegal-function one.
+func decodeMultipleWriteRequest(data []byte) (startRegister, numRegisters uint16, payload []byte, err error) {
+	if len(data) < 5 {
+		return 0, 0, nil, MODBUS_EXCEPTIONS[EXCEPTION_DATA_VALUE]
+	}
+	startRegister = uint16(data[0])<<8 | uint16(data[1])
+	numRegisters = uint16(data[2])<<8 | uint16(data[3])
+	byteCount := int(data[4])
+	if len(data) < 5+byteCount {
+		return 0, 0, nil, MODBUS_EXCEPTIONS[EXCEPTION_DATA_VALUE]
+	}
+	return startRegister, numRegisters, data[5 : 5+byteCount], nil
+}
+
+// encodeReadResponse frames a read reply's byte count ahead of its data,
+// as FUNCTION_READ_* responses require.
+func encodeReadResponse(data []byte) []byte {
+	resp := make([]byte, 1+len(data))
+	resp[0] = byte(len(data))
+	copy(resp[1:], data)
+	return resp
+}
+
+// exceptionPDU builds a response PDU carrying the given modbus exception
+// code for the given function code.
+func exceptionPDU(functionCode, exceptionCode byte) []byte {
+	return []byte{functionCode | 0x80, exceptionCode}
+}
+
+// exceptionCodeFor maps an error returned by a ProtocolHandler callback
+// to the modbus exception code reported back to the master. Errors not
+// already one of MODBUS_EXCEPTIONS are reported as a slave device failure.
+func exceptionCodeFor(err error) byte {
+	for code, exceptionErr := range MODBUS_EXCEPTIONS {
+		if exceptionErr == err {
+			return code
+		}
+	}
+	return EXCEPTION_SLAVE_DEVICE_FAILURE
+}
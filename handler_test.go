@@ -0,0 +1,113 @@
+package modbusclient
+
+import (
+	"errors"
+	"testing"
+)
+
+// fakeHandler is a ProtocolHandler whose callbacks return canned values,
+// used to exercise dispatchRequest without any real serial I/O.
+type fakeHandler struct {
+	holdingRegisters []byte
+	writeErr         error
+	lastWriteReg     uint16
+	lastWriteValue   uint16
+	errs             []error
+}
+
+func (h *fakeHandler) ReadCoils(start, num uint16) ([]byte, error) { return nil, nil }
+func (h *fakeHandler) ReadDiscreteInputs(start, num uint16) ([]byte, error) {
+	return nil, nil
+}
+func (h *fakeHandler) ReadHoldingRegisters(start, num uint16) ([]byte, error) {
+	return h.holdingRegisters, nil
+}
+func (h *fakeHandler) ReadInputRegisters(start, num uint16) ([]byte, error) { return nil, nil }
+func (h *fakeHandler) WriteSingleCoil(register, value uint16) error         { return h.writeErr }
+func (h *fakeHandler) WriteSingleRegister(register, value uint16) error {
+	h.lastWriteReg, h.lastWriteValue = register, value
+	return h.writeErr
+}
+func (h *fakeHandler) WriteMultipleCoils(start, num uint16, data []byte) error     { return h.writeErr }
+func (h *fakeHandler) WriteMultipleRegisters(start, num uint16, data []byte) error { return h.writeErr }
+func (h *fakeHandler) OnError(err error)                                           { h.errs = append(h.errs, err) }
+
+func TestDispatchRequestReadHoldingRegisters(t *testing.T) {
+	handler := &fakeHandler{holdingRegisters: []byte{0x00, 0x2a}}
+	request := []byte{FUNCTION_READ_HOLDING_REGISTERS, 0x00, 0x00, 0x00, 0x01}
+
+	got := dispatchRequest(handler, request)
+	want := []byte{FUNCTION_READ_HOLDING_REGISTERS, 0x02, 0x00, 0x2a}
+	if string(got) != string(want) {
+		t.Errorf("dispatchRequest() = %x, want %x", got, want)
+	}
+}
+
+func TestDispatchRequestWriteSingleRegister(t *testing.T) {
+	handler := &fakeHandler{}
+	request := []byte{FUNCTION_WRITE_SINGLE_REGISTER, 0x00, 0x05, 0x00, 0x2a}
+
+	got := dispatchRequest(handler, request)
+	if string(got) != string(request) {
+		t.Errorf("dispatchRequest() = %x, want echo of request %x", got, request)
+	}
+	if handler.lastWriteReg != 5 || handler.lastWriteValue != 0x2a {
+		t.Errorf("handler saw register=%d value=%d, want register=5 value=42", handler.lastWriteReg, handler.lastWriteValue)
+	}
+}
+
+func TestDispatchRequestHandlerErrorBecomesException(t *testing.T) {
+	handler := &fakeHandler{writeErr: MODBUS_EXCEPTIONS[EXCEPTION_DATA_VALUE]}
+	request := []byte{FUNCTION_WRITE_SINGLE_REGISTER, 0x00, 0x05, 0x00, 0x2a}
+
+	got := dispatchRequest(handler, request)
+	want := []byte{FUNCTION_WRITE_SINGLE_REGISTER | 0x80, EXCEPTION_DATA_VALUE}
+	if string(got) != string(want) {
+		t.Errorf("dispatchRequest() = %x, want exception response %x", got, want)
+	}
+	if len(handler.errs) != 1 {
+		t.Errorf("OnError called %d times, want 1", len(handler.errs))
+	}
+}
+
+func TestDispatchRequestUnknownFunctionCode(t *testing.T) {
+	handler := &fakeHandler{}
+	got := dispatchRequest(handler, []byte{0x55})
+	want := []byte{0x55 | 0x80, EXCEPTION_ILLEGAL_FUNCTION}
+	if string(got) != string(want) {
+		t.Errorf("dispatchRequest() = %x, want %x", got, want)
+	}
+}
+
+func TestDispatchRequestTruncatedReadRequest(t *testing.T) {
+	handler := &fakeHandler{}
+	got := dispatchRequest(handler, []byte{FUNCTION_READ_HOLDING_REGISTERS, 0x00})
+	want := []byte{FUNCTION_READ_HOLDING_REGISTERS | 0x80, EXCEPTION_ILLEGAL_FUNCTION}
+	if string(got) != string(want) {
+		t.Errorf("dispatchRequest() = %x, want %x", got, want)
+	}
+}
+
+func TestDispatchRequestTruncatedWriteSingleRequest(t *testing.T) {
+	handler := &fakeHandler{}
+	got := dispatchRequest(handler, []byte{FUNCTION_WRITE_SINGLE_REGISTER, 0x00, 0x05})
+	want := []byte{FUNCTION_WRITE_SINGLE_REGISTER | 0x80, EXCEPTION_DATA_VALUE}
+	if string(got) != string(want) {
+		t.Errorf("dispatchRequest() = %x, want %x", got, want)
+	}
+}
+
+func TestDispatchRequestTruncatedWriteMultipleRequest(t *testing.T) {
+	handler := &fakeHandler{}
+	got := dispatchRequest(handler, []byte{FUNCTION_WRITE_MULTIPLE_REGISTERS, 0x00, 0x00, 0x00})
+	want := []byte{FUNCTION_WRITE_MULTIPLE_REGISTERS | 0x80, EXCEPTION_DATA_VALUE}
+	if string(got) != string(want) {
+		t.Errorf("dispatchRequest() = %x, want %x", got, want)
+	}
+}
+
+func TestExceptionCodeForUnmappedErrorIsSlaveDeviceFailure(t *testing.T) {
+	if got := exceptionCodeFor(errors.New("boom")); got != EXCEPTION_SLAVE_DEVICE_FAILURE {
+		t.Errorf("exceptionCodeFor(unmapped) = %#x, want %#x", got, EXCEPTION_SLAVE_DEVICE_FAILURE)
+	}
+}
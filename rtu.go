@@ -5,6 +5,7 @@
 package modbusclient
 
 import (
+	"errors"
 	"fmt"
 	"github.com/tarm/serial"
 	"io"
@@ -41,11 +42,12 @@ func crc(data []byte) uint16 {
 	return crc16
 }
 
-// GenerateRTUFrame is a method corresponding to a RTUFrame object which
-// returns a byte array representing the associated serial line/RTU
-// application data unit (ADU)
-func (frame *RTUFrame) GenerateRTUFrame() []byte {
-
+// buildPDU assembles the slave address, function code, start register,
+// and (depending on the function code) number-of-registers/data-length
+// and data fields common to both the RTU and ASCII transmission modes,
+// leaving the caller to append its own error-check field (CRC-16 for
+// RTU, LRC for ASCII).
+func (frame *RTUFrame) buildPDU() []byte {
 	insertNumOfRegister := true
 	insertDataLen := false
 	switch frame.FunctionCode {
@@ -81,6 +83,19 @@ func (frame *RTUFrame) GenerateRTUFrame() []byte {
 	}
 	bytesUsed += dataLen
 
+	return packet[:bytesUsed]
+}
+
+// GenerateRTUFrame is a method corresponding to a RTUFrame object which
+// returns a byte array representing the associated serial line/RTU
+// application data unit (ADU)
+func (frame *RTUFrame) GenerateRTUFrame() []byte {
+	pdu := frame.buildPDU()
+
+	packet := make([]byte, len(pdu)+2)
+	copy(packet, pdu)
+	bytesUsed := len(pdu)
+
 	// add the crc to the end
 	packetCrc := crc(packet[:bytesUsed])
 	packet[bytesUsed] = byte(packetCrc & 0xff)
@@ -90,9 +105,39 @@ func (frame *RTUFrame) GenerateRTUFrame() []byte {
 	return packet[:bytesUsed]
 }
 
+// InterCharacterTimeout returns the maximum permissible gap between two
+// characters within the same RTU frame (t1.5), per the Modbus-over-
+// serial-line spec. At baud rates of 19200 or higher the gap is fixed
+// at 750us regardless of baud rate; below that it scales with the time
+// it takes to transmit 1.5 characters (11 bits each: start, 8 data,
+// parity, stop) at the given rate.
+func InterCharacterTimeout(baudRate int) time.Duration {
+	if baudRate >= 19200 {
+		return 750 * time.Microsecond
+	}
+	charTime := time.Duration(11) * time.Second / time.Duration(baudRate)
+	return charTime * 3 / 2
+}
+
+// InterFrameTimeout returns the minimum silence on the bus that marks
+// the end of one RTU frame and the start of another (t3.5), per the
+// Modbus-over-serial-line spec. At baud rates of 19200 or higher the
+// gap is fixed at 1.75ms regardless of baud rate; below that it scales
+// with the time it takes to transmit 3.5 characters at the given rate.
+func InterFrameTimeout(baudRate int) time.Duration {
+	if baudRate >= 19200 {
+		return 1750 * time.Microsecond
+	}
+	charTime := time.Duration(11) * time.Second / time.Duration(baudRate)
+	return charTime * 7 / 2
+}
+
 // ConnectRTU attempts to access the Serial Device for subsequent
 // RTU writes and response reads from the modbus slave device
 func ConnectRTU(cfg RTUConfig) (*RTUContext, error) {
+	if cfg.Serial.ReadTimeout == 0 {
+		cfg.Serial.ReadTimeout = InterCharacterTimeout(cfg.Serial.Baud)
+	}
 	port, err := serial.OpenPort(&cfg.Serial)
 	if err != nil {
 		return nil, err
@@ -143,25 +188,114 @@ func (rtu *RTUContext) viaRTU(fnValidator func(byte) bool, functionCode byte, st
 			return []byte{}, werr
 		}
 
-		// allow the slave device adequate time to respond
-		time.Sleep(rtu.Timeout)
+		// wait for a correctly-addressed reply, silently discarding
+		// any frame from a different slave, until the deadline passes
+		return rtu.awaitRTUResponse(frame)
+	}
+
+	return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION]
+}
+
+// errRTUReadTimeout is returned internally by rtuFrameReader.next when
+// its deadline passes without a complete frame arriving.
+var errRTUReadTimeout = errors.New("modbus: rtu frame read timed out")
+
+// rtuFrameReader reassembles complete RTU frames out of a stream of
+// reads from r, honoring the spec's t1.5/t3.5 inter-character/
+// inter-frame timing, rather than trusting a single Read to return an
+// entire ADU. r's ReadTimeout (set by ConnectRTU to the t1.5
+// inter-character gap) makes each Read return promptly whenever the bus
+// falls silent; a frame is considered complete once the expected length
+// for it has arrived, or, failing that, once t3.5 of silence has passed
+// since the last byte. It is shared by the client (awaitRTUResponse) and
+// the server (ServeRTU), which differ only in how a frame's expected
+// length is derived from its function code.
+type rtuFrameReader struct {
+	r          io.Reader
+	baudRate   int
+	buf        []byte
+	lastByteAt time.Time
+}
+
+func newRTUFrameReader(r io.Reader, baudRate int) *rtuFrameReader {
+	return &rtuFrameReader{r: r, baudRate: baudRate}
+}
+
+// next blocks until extract reports a complete frame at the start of
+// the reader's buffered bytes, or until deadline passes (a zero
+// deadline never expires). On success, the returned frame's bytes are
+// consumed from the reader's internal buffer, so a subsequent next call
+// picks up right after it without losing any bytes read ahead of it.
+func (fr *rtuFrameReader) next(deadline time.Time, extract func([]byte) ([]byte, bool)) ([]byte, error) {
+	interFrameGap := InterFrameTimeout(fr.baudRate)
+
+	for {
+		frame, ok := extract(fr.buf)
+		if !ok && len(fr.buf) >= 3 && !fr.lastByteAt.IsZero() && time.Since(fr.lastByteAt) >= interFrameGap {
+			// extract couldn't derive this frame's length up front,
+			// but the bus has been silent for a full inter-frame gap,
+			// so treat whatever arrived as the complete frame. The
+			// same len(buf) >= 3 floor extractRTUFrame enforces applies here
+			// too, so a 1- or 2-byte noise fragment can never be
+			// mistaken for a full frame just because the bus then
+			// goes quiet.
+			frame, ok = fr.buf, true
+		}
+		if ok {
+			fr.buf = fr.buf[len(frame):]
+			return frame, nil
+		}
+
+		if !deadline.IsZero() && time.Now().After(deadline) {
+			return nil, errRTUReadTimeout
+		}
 
-		// then attempt to read the reply
-		response := make([]byte, RTU_FRAME_MAXSIZE)
-		n, rerr := rtu.Read(response)
+		chunk := make([]byte, RTU_FRAME_MAXSIZE)
+		n, err := fr.r.Read(chunk)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			fr.buf = append(fr.buf, chunk[:n]...)
+			fr.lastByteAt = time.Now()
+		}
+	}
+}
+
+// awaitRTUResponse reads frames via a rtuFrameReader until one addressed
+// to us arrives or rtu.Timeout elapses since the request was sent. Per
+// the Modbus-over-serial-line spec section 2.4.1, a reply whose slave
+// address does not match the one just addressed must be silently
+// discarded rather than treated as an error, since stray traffic from
+// other slaves may share the bus.
+func (rtu *RTUContext) awaitRTUResponse(frame *RTUFrame) ([]byte, error) {
+	deadline := time.Now().Add(rtu.Timeout)
+	reader := newRTUFrameReader(rtu, rtu.Serial.Baud)
+
+	for {
+		response, rerr := reader.next(deadline, extractRTUFrame)
 		if rerr != nil {
+			if rerr == errRTUReadTimeout {
+				if rtu.Debug {
+					log.Println("RTU Read Timeout")
+				}
+				return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_UNSPECIFIED]
+			}
 			if rtu.Debug {
 				log.Println(fmt.Sprintf("RTU Read Err: %s", rerr))
 			}
 			return []byte{}, rerr
 		}
 
-		// check the validity of the response
-		if response[0] != frame.SlaveAddress || response[1] != frame.FunctionCode {
+		if response[0] != frame.SlaveAddress {
 			if rtu.Debug {
-				log.Println("RTU Response Invalid")
+				log.Println(fmt.Sprintf("RTU Response Discarded (unexpected slave %d): %x", response[0], response))
 			}
-			if response[0] == frame.SlaveAddress && (response[1]&0x7f) == frame.FunctionCode {
+			continue
+		}
+
+		if response[1] != frame.FunctionCode {
+			if (response[1] & 0x7f) == frame.FunctionCode {
 				switch response[2] {
 				case EXCEPTION_ILLEGAL_FUNCTION:
 					return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION]
@@ -173,10 +307,14 @@ func (rtu *RTUContext) viaRTU(fnValidator func(byte) bool, functionCode byte, st
 					return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_SLAVE_DEVICE_FAILURE]
 				}
 			}
+			if rtu.Debug {
+				log.Println("RTU Response Invalid")
+			}
 			return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_UNSPECIFIED]
 		}
 
 		// confirm the checksum (crc)
+		n := len(response)
 		responseCrc := crc(response[:(n - 2)])
 		if response[(n-2)] != byte((responseCrc&0xff)) ||
 			response[(n-1)] != byte((responseCrc>>8)) {
@@ -185,14 +323,55 @@ func (rtu *RTUContext) viaRTU(fnValidator func(byte) bool, functionCode byte, st
 				log.Println("RTU Response Invalid: Bad Checksum")
 			}
 			// return the response bytes anyway, and let the caller decide
-			return response[:n], MODBUS_EXCEPTIONS[EXCEPTION_BAD_CHECKSUM]
+			return response, MODBUS_EXCEPTIONS[EXCEPTION_BAD_CHECKSUM]
 		}
 
-		// return only the number of bytes read
-		return response[:n], nil
+		return response, nil
 	}
+}
 
-	return []byte{}, MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION]
+// extractRTUFrame inspects buf for a complete RTU frame at its start,
+// using the spec-defined length rules for the response's function code
+// (a byte count at offset 2 for read replies, a fixed 8 bytes for write
+// replies, 5 bytes for exceptions). It returns the frame and true once
+// enough bytes have arrived, or false if buf is still incomplete (or, for
+// an unrecognized function code whose length can't be derived this way,
+// always false — rtuFrameReader.next falls back to the t3.5 silence gap to
+// decide such a frame is complete).
+func extractRTUFrame(buf []byte) ([]byte, bool) {
+	if len(buf) < 3 {
+		return nil, false
+	}
+
+	functionCode := buf[1]
+	if functionCode&0x80 != 0 {
+		// exception reply: address, function|0x80, exception code, crc lo/hi
+		if len(buf) < 5 {
+			return nil, false
+		}
+		return buf[:5], true
+	}
+
+	var frameLen int
+	switch functionCode {
+	case FUNCTION_READ_COILS, FUNCTION_READ_DISCRETE_INPUTS,
+		FUNCTION_READ_HOLDING_REGISTERS, FUNCTION_READ_INPUT_REGISTERS:
+		// address, function, byte count, <byte count> bytes of data, crc lo/hi
+		frameLen = 3 + int(buf[2]) + 2
+	case FUNCTION_WRITE_SINGLE_COIL, FUNCTION_WRITE_SINGLE_REGISTER,
+		FUNCTION_WRITE_MULTIPLE_COILS, FUNCTION_WRITE_MULTIPLE_REGISTERS:
+		// address, function, start register hi/lo, value/count hi/lo, crc lo/hi
+		frameLen = 8
+	default:
+		// unknown function code; length can't be derived, so report
+		// incomplete and let the inter-frame silence gap decide
+		return nil, false
+	}
+
+	if len(buf) < frameLen {
+		return nil, false
+	}
+	return buf[:frameLen], true
 }
 
 // RTURead performs the given modbus Read function over RTU to the given
@@ -0,0 +1,103 @@
+package modbusclient
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestCRCKnownVector(t *testing.T) {
+	// read holding registers request: slave 1, start 0, quantity 10
+	request := []byte{0x01, FUNCTION_READ_HOLDING_REGISTERS, 0x00, 0x00, 0x00, 0x0a}
+	got := crc(request)
+	if lo, hi := byte(got&0xff), byte(got>>8); lo != 0xc5 || hi != 0xcd {
+		t.Errorf("crc() = %04x, want cdc5 (on the wire: c5 cd)", got)
+	}
+}
+
+func TestExtractRTUFrameReadReply(t *testing.T) {
+	data := []byte{0x00, 0x2a}
+	reply := []byte{0x01, FUNCTION_READ_HOLDING_REGISTERS, byte(len(data))}
+	reply = append(reply, data...)
+	replyCrc := crc(reply)
+	reply = append(reply, byte(replyCrc&0xff), byte(replyCrc>>8))
+
+	frame, ok := extractRTUFrame(reply)
+	if !ok || len(frame) != len(reply) {
+		t.Fatalf("extractRTUFrame(%x) = (%x, %v), want complete frame", reply, frame, ok)
+	}
+
+	if _, ok := extractRTUFrame(reply[:len(reply)-1]); ok {
+		t.Error("extractRTUFrame() reported a truncated read reply as complete")
+	}
+}
+
+func TestExtractRTUFrameExceptionReply(t *testing.T) {
+	reply := []byte{0x01, FUNCTION_READ_HOLDING_REGISTERS | 0x80, EXCEPTION_DATA_ADDRESS}
+	replyCrc := crc(reply)
+	reply = append(reply, byte(replyCrc&0xff), byte(replyCrc>>8))
+
+	frame, ok := extractRTUFrame(reply)
+	if !ok || len(frame) != 5 {
+		t.Fatalf("extractRTUFrame(%x) = (%x, %v), want a 5-byte exception frame", reply, frame, ok)
+	}
+}
+
+func TestExtractRTURequestFrameWriteMultipleRegisters(t *testing.T) {
+	data := []byte{0x00, 0x2a, 0x00, 0x2b}
+	req := []byte{0x01, FUNCTION_WRITE_MULTIPLE_REGISTERS, 0x00, 0x00, 0x00, 0x02, byte(len(data))}
+	req = append(req, data...)
+	reqCrc := crc(req)
+	req = append(req, byte(reqCrc&0xff), byte(reqCrc>>8))
+
+	frame, ok := extractRTURequestFrame(req)
+	if !ok || len(frame) != len(req) {
+		t.Fatalf("extractRTURequestFrame(%x) = (%x, %v), want complete frame", req, frame, ok)
+	}
+
+	if _, ok := extractRTURequestFrame(req[:7]); ok {
+		t.Error("extractRTURequestFrame() reported a truncated write-multiple request as complete")
+	}
+}
+
+// fakeSerialReader feeds back a fixed sequence of reads, one per call,
+// standing in for a real serial.Port for rtuFrameReader tests.
+type fakeSerialReader struct {
+	chunks [][]byte
+}
+
+func (f *fakeSerialReader) Read(p []byte) (int, error) {
+	if len(f.chunks) == 0 {
+		return 0, nil
+	}
+	chunk := f.chunks[0]
+	f.chunks = f.chunks[1:]
+	return copy(p, chunk), nil
+}
+
+func TestRTUFrameReaderReassemblesSplitReads(t *testing.T) {
+	reply := []byte{0x01, FUNCTION_READ_HOLDING_REGISTERS, 0x02, 0x00, 0x2a}
+	replyCrc := crc(reply)
+	reply = append(reply, byte(replyCrc&0xff), byte(replyCrc>>8))
+
+	fake := &fakeSerialReader{chunks: [][]byte{reply[:2], reply[2:]}}
+	reader := newRTUFrameReader(fake, 19200)
+
+	frame, err := reader.next(time.Now().Add(time.Second), extractRTUFrame)
+	if err != nil {
+		t.Fatalf("next() error = %v", err)
+	}
+	if !bytes.Equal(frame, reply) {
+		t.Errorf("next() = %x, want %x", frame, reply)
+	}
+}
+
+func TestRTUFrameReaderTimesOut(t *testing.T) {
+	fake := &fakeSerialReader{}
+	reader := newRTUFrameReader(fake, 19200)
+
+	_, err := reader.next(time.Now().Add(time.Millisecond), extractRTUFrame)
+	if err != errRTUReadTimeout {
+		t.Errorf("next() error = %v, want errRTUReadTimeout", err)
+	}
+}
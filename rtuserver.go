@@ -0,0 +1,133 @@
+package modbusclient
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// ServeRTU opens the serial device described by cfg and runs a modbus RTU
+// server (slave) loop against it: every inbound ADU addressed to
+// cfg.SlaveAddress is decoded, dispatched to the given ProtocolHandler,
+// and answered with a CRC-framed reply (or the appropriate exception
+// response). A request whose CRC does not match is passed to
+// handler.OnError and left unanswered. It blocks until a read from or a
+// write to the serial device fails (for example because the caller
+// closed it), at which point that error is returned.
+//
+// A TCP counterpart, ServeTCP, is intentionally not part of this change:
+// this module does not yet have a TCP client/context of its own to serve
+// alongside, so there is no TCP file to add it to. ServeTCP should follow
+// the same ProtocolHandler/dispatchRequest split once one exists.
+func ServeRTU(cfg RTUConfig, handler ProtocolHandler) error {
+	rtu, err := ConnectRTU(cfg)
+	if err != nil {
+		return err
+	}
+	defer DisconnectRTU(rtu)
+
+	return serveRTU(rtu, cfg.Serial.Baud, cfg, handler)
+}
+
+// serveRTU is ServeRTU's request/dispatch/response loop, taking rw and
+// baud (rather than reaching into a *RTUContext itself) so it can be
+// driven in tests against a net.Pipe/io.Pipe loopback instead of a real
+// serial device. Inbound ADUs are reassembled with the same t1.5/t3.5
+// framing-aware rtuFrameReader the client side uses, rather than a bare
+// single Read, so a request isn't mis-split or truncated under the same
+// conditions (USB-serial adapters with small FIFOs) that motivate that
+// reader.
+func serveRTU(rw io.ReadWriter, baud int, cfg RTUConfig, handler ProtocolHandler) error {
+	reader := newRTUFrameReader(rw, baud)
+
+	for {
+		adu, rerr := reader.next(noDeadline, extractRTURequestFrame)
+		if rerr != nil {
+			return rerr
+		}
+
+		if cfg.Debug {
+			log.Println(fmt.Sprintf("Rx: %x", adu))
+		}
+
+		if adu[0] != cfg.SlaveAddress {
+			// not addressed to us; a real slave stays silent rather
+			// than answering for another device's traffic
+			continue
+		}
+
+		requestCrc := crc(adu[:(len(adu) - 2)])
+		if adu[len(adu)-2] != byte(requestCrc&0xff) || adu[len(adu)-1] != byte(requestCrc>>8) {
+			handler.OnError(MODBUS_EXCEPTIONS[EXCEPTION_BAD_CHECKSUM])
+			continue
+		}
+
+		responsePDU := dispatchRequest(handler, adu[1:len(adu)-2])
+		response := buildRTUResponse(cfg.SlaveAddress, responsePDU)
+
+		if cfg.Debug {
+			log.Println(fmt.Sprintf("Tx: %x", response))
+		}
+
+		if _, werr := rw.Write(response); werr != nil {
+			return werr
+		}
+	}
+}
+
+// buildRTUResponse wraps a response PDU (as returned by dispatchRequest)
+// with the slave address and trailing CRC to form a complete RTU ADU.
+func buildRTUResponse(slaveAddress byte, pdu []byte) []byte {
+	adu := make([]byte, len(pdu)+3)
+	adu[0] = slaveAddress
+	copy(adu[1:], pdu)
+	responseCrc := crc(adu[:len(adu)-2])
+	adu[len(adu)-2] = byte(responseCrc & 0xff)
+	adu[len(adu)-1] = byte(responseCrc >> 8)
+	return adu
+}
+
+// noDeadline tells rtuFrameReader.next to wait indefinitely: a server
+// has no per-transaction timeout of its own, unlike a client waiting on
+// a single slave's reply.
+var noDeadline time.Time
+
+// extractRTURequestFrame inspects buf for a complete inbound RTU request
+// frame (master->slave) at its start. It mirrors extractRTUFrame's
+// contract but for the request side of the wire, which has a different
+// shape for the same function codes: requests have no byte-count-
+// prefixed reply body, so the read functions and the two single-write
+// functions are all a fixed 8 bytes, and only the two multiple-write
+// functions carry a byte count (at offset 6, after the starting
+// register and quantity, rather than offset 2).
+func extractRTURequestFrame(buf []byte) ([]byte, bool) {
+	if len(buf) < 2 {
+		return nil, false
+	}
+
+	var frameLen int
+	switch buf[1] {
+	case FUNCTION_READ_COILS, FUNCTION_READ_DISCRETE_INPUTS,
+		FUNCTION_READ_HOLDING_REGISTERS, FUNCTION_READ_INPUT_REGISTERS,
+		FUNCTION_WRITE_SINGLE_COIL, FUNCTION_WRITE_SINGLE_REGISTER:
+		// address, function, register hi/lo, count/value hi/lo, crc lo/hi
+		frameLen = 8
+	case FUNCTION_WRITE_MULTIPLE_COILS, FUNCTION_WRITE_MULTIPLE_REGISTERS:
+		// address, function, start register hi/lo, num registers hi/lo,
+		// byte count, <byte count> bytes of data, crc lo/hi
+		if len(buf) < 7 {
+			return nil, false
+		}
+		frameLen = 7 + int(buf[6]) + 2
+	default:
+		// unknown function code; length can't be derived, so report
+		// incomplete and let the inter-frame silence gap decide
+		return nil, false
+	}
+
+	if len(buf) < frameLen {
+		return nil, false
+	}
+	return buf[:frameLen], true
+}
@@ -0,0 +1,47 @@
+package modbusclient
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestServeRTULoopbackReadHoldingRegisters(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	handler := &fakeHandler{holdingRegisters: []byte{0x00, 0x2a}}
+	cfg := RTUConfig{SlaveAddress: 0x01}
+
+	done := make(chan error, 1)
+	go func() { done <- serveRTU(server, 19200, cfg, handler) }()
+
+	frame := &RTUFrame{
+		SlaveAddress:      cfg.SlaveAddress,
+		FunctionCode:      FUNCTION_READ_HOLDING_REGISTERS,
+		StartRegister:     0,
+		NumberOfRegisters: 1,
+	}
+	if _, err := client.Write(frame.GenerateRTUFrame()); err != nil {
+		t.Fatalf("client.Write() error = %v", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	response := make([]byte, RTU_FRAME_MAXSIZE)
+	n, err := client.Read(response)
+	if err != nil {
+		t.Fatalf("client.Read() error = %v", err)
+	}
+	response = response[:n]
+
+	want := buildRTUResponse(cfg.SlaveAddress, []byte{FUNCTION_READ_HOLDING_REGISTERS, 0x02, 0x00, 0x2a})
+	if !bytes.Equal(response, want) {
+		t.Errorf("serveRTU response = %x, want %x", response, want)
+	}
+
+	client.Close()
+	if err := <-done; err == nil {
+		t.Error("serveRTU() should have returned an error once the pipe was closed")
+	}
+}
@@ -0,0 +1,128 @@
+// Package modbusclient provides modbus Serial Line/RTU and TCP/IP access
+// for client (master) applications to communicate with server (slave)
+// devices. Logic in this file implements ScanBus, a bus scanner for
+// commissioning RS-485 drops.
+package modbusclient
+
+import (
+	"context"
+	"time"
+)
+
+// defaultScanProbeTimeout is used in place of rtu.Timeout for each
+// probe when ScanBus is called with probeTimeout <= 0. It's short
+// because an absent address (the common case across a 1..247 sweep)
+// has to be waited out in full for every address scanned, unlike an
+// ordinary transaction's timeout, which is tuned for production reads
+// against a device known to be present.
+const defaultScanProbeTimeout = 200 * time.Millisecond
+
+// ScanStatus classifies how a candidate slave address responded (or
+// didn't) to a ScanBus probe.
+type ScanStatus int
+
+const (
+	// ScanPresent means the probe got a valid reply from the address.
+	ScanPresent ScanStatus = iota
+	// ScanAbsent means the probe failed with EXCEPTION_UNSPECIFIED. That
+	// is what RTURead reports when no correctly-addressed reply arrived
+	// before the probe's timeout, i.e. nothing is listening at that
+	// address, but it reports the same for a correctly-addressed reply it
+	// cannot interpret (an unexpected function code, or an exception code
+	// other than the four below), so such an address is also ScanAbsent.
+	ScanAbsent
+	// ScanException means the address replied with an illegal function,
+	// illegal data address, illegal data value, or slave device failure
+	// exception; ScanResult.ExceptionCode holds which one.
+	ScanException
+	// ScanCRCError means the address replied, but the reply's CRC did
+	// not match its contents.
+	ScanCRCError
+)
+
+// ScanResult is the outcome of probing a single candidate slave address.
+type ScanResult struct {
+	Status        ScanStatus
+	ExceptionCode byte // valid only when Status == ScanException
+}
+
+// defaultScanAddresses is the full range of valid modbus slave
+// addresses, used when ScanBus is not given an explicit address list.
+func defaultScanAddresses() []byte {
+	addrs := make([]byte, 247)
+	for i := range addrs {
+		addrs[i] = byte(i + 1)
+	}
+	return addrs
+}
+
+// ScanBus probes each address in addrs (or every address 1..247, if
+// addrs is empty) in turn, using probe to build a small read request for
+// that address, and classifies the result. Each probe waits at most
+// probeTimeout for a reply rather than rtu.Timeout, since the common
+// case across a sweep — nothing listening at a given address — means
+// waiting out the full timeout for most addresses; probeTimeout <= 0
+// uses defaultScanProbeTimeout instead. It reuses rtu's RTUConfig
+// (including its SlaveAddress and Timeout) for the duration of the scan
+// and restores both before returning, so the caller's rtu can safely be
+// reused for ordinary transactions afterwards. It returns early with
+// whatever results were gathered so far if ctx is done, or if a probe
+// fails for a reason other than the per-address classifications above
+// (for example, the serial device itself went away).
+func ScanBus(ctx context.Context, rtu *RTUContext, addrs []byte, probeTimeout time.Duration, probe func(byte) (startRegister, numRegisters uint16, functionCode byte)) (map[byte]ScanResult, error) {
+	if len(addrs) == 0 {
+		addrs = defaultScanAddresses()
+	}
+	if probeTimeout <= 0 {
+		probeTimeout = defaultScanProbeTimeout
+	}
+
+	originalAddress := rtu.SlaveAddress
+	originalTimeout := rtu.Timeout
+	defer func() {
+		rtu.SlaveAddress = originalAddress
+		rtu.Timeout = originalTimeout
+	}()
+	rtu.Timeout = probeTimeout
+
+	results := make(map[byte]ScanResult, len(addrs))
+	for _, addr := range addrs {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		startRegister, numRegisters, functionCode := probe(addr)
+		rtu.SlaveAddress = addr
+		_, err := rtu.RTURead(functionCode, startRegister, numRegisters)
+
+		result, ok := classifyScanResult(err)
+		if !ok {
+			return results, err
+		}
+		results[addr] = result
+	}
+
+	return results, nil
+}
+
+// classifyScanResult maps the error (if any) from a ScanBus probe to a
+// ScanResult. The second return value is false if err doesn't correspond
+// to one of the known per-address outcomes and should instead abort the
+// scan.
+func classifyScanResult(err error) (ScanResult, bool) {
+	if err == nil {
+		return ScanResult{Status: ScanPresent}, true
+	}
+	if err == MODBUS_EXCEPTIONS[EXCEPTION_UNSPECIFIED] {
+		return ScanResult{Status: ScanAbsent}, true
+	}
+	if err == MODBUS_EXCEPTIONS[EXCEPTION_BAD_CHECKSUM] {
+		return ScanResult{Status: ScanCRCError}, true
+	}
+	for _, code := range []byte{EXCEPTION_ILLEGAL_FUNCTION, EXCEPTION_DATA_ADDRESS, EXCEPTION_DATA_VALUE, EXCEPTION_SLAVE_DEVICE_FAILURE} {
+		if err == MODBUS_EXCEPTIONS[code] {
+			return ScanResult{Status: ScanException, ExceptionCode: code}, true
+		}
+	}
+	return ScanResult{}, false
+}
@@ -0,0 +1,55 @@
+package modbusclient
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClassifyScanResult(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ScanResult
+		ok   bool
+	}{
+		{"nil", nil, ScanResult{Status: ScanPresent}, true},
+		{"unspecified", MODBUS_EXCEPTIONS[EXCEPTION_UNSPECIFIED], ScanResult{Status: ScanAbsent}, true},
+		{"bad checksum", MODBUS_EXCEPTIONS[EXCEPTION_BAD_CHECKSUM], ScanResult{Status: ScanCRCError}, true},
+		{"illegal function", MODBUS_EXCEPTIONS[EXCEPTION_ILLEGAL_FUNCTION], ScanResult{Status: ScanException, ExceptionCode: EXCEPTION_ILLEGAL_FUNCTION}, true},
+		{"data address", MODBUS_EXCEPTIONS[EXCEPTION_DATA_ADDRESS], ScanResult{Status: ScanException, ExceptionCode: EXCEPTION_DATA_ADDRESS}, true},
+		{"data value", MODBUS_EXCEPTIONS[EXCEPTION_DATA_VALUE], ScanResult{Status: ScanException, ExceptionCode: EXCEPTION_DATA_VALUE}, true},
+		{"slave device failure", MODBUS_EXCEPTIONS[EXCEPTION_SLAVE_DEVICE_FAILURE], ScanResult{Status: ScanException, ExceptionCode: EXCEPTION_SLAVE_DEVICE_FAILURE}, true},
+		{"unmapped", errors.New("serial device went away"), ScanResult{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := classifyScanResult(tt.err)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("classifyScanResult(%v) = (%v, %v), want (%v, %v)", tt.err, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestScanBusRestoresConfigOnCtxDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	rtu := &RTUContext{RTUConfig: RTUConfig{SlaveAddress: 0x05, Timeout: time.Second}}
+
+	_, err := ScanBus(ctx, rtu, []byte{1, 2, 3}, 0, func(byte) (uint16, uint16, byte) {
+		return 0, 1, FUNCTION_READ_HOLDING_REGISTERS
+	})
+	if err != context.Canceled {
+		t.Fatalf("ScanBus() error = %v, want context.Canceled", err)
+	}
+	if rtu.SlaveAddress != 0x05 {
+		t.Errorf("rtu.SlaveAddress = %#x after ScanBus(), want original 0x05", rtu.SlaveAddress)
+	}
+	if rtu.Timeout != time.Second {
+		t.Errorf("rtu.Timeout = %v after ScanBus(), want original 1s", rtu.Timeout)
+	}
+}
@@ -0,0 +1,174 @@
+// Package modbusclient provides modbus Serial Line/RTU and TCP/IP access
+// for client (master) applications to communicate with server (slave)
+// devices. Logic in this file implements Session, a transaction wrapper
+// that adds concurrency-safety, context cancellation, and retries on
+// top of the one-shot RTURead/RTUWrite calls.
+package modbusclient
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Request describes a single modbus transaction to run through a
+// Session, mirroring the arguments RTURead/RTUWrite already take.
+type Request struct {
+	FunctionCode      byte
+	StartRegister     uint16
+	NumberOfRegisters uint16
+	Data              []byte
+}
+
+// Response is the result of a successful Session transaction.
+type Response struct {
+	Data []byte
+}
+
+// RetryPredicate decides, given the number of attempts already made and
+// the error the most recent one failed with, whether Session.Do should
+// retry. attempt is 1 on the first retry decision (i.e. after the
+// initial attempt has already failed once).
+type RetryPredicate func(attempt int, err error) bool
+
+// rtuTransaction is the subset of *RTUContext that Session drives. It's
+// extracted as an interface, rather than Session holding a *RTUContext
+// directly, so the concurrency/cancellation/retry logic below can be
+// exercised in tests against a fake bus instead of a real serial device.
+type rtuTransaction interface {
+	RTURead(functionCode byte, startRegister, numRegisters uint16) ([]byte, error)
+	RTUWrite(functionCode byte, startRegister, numRegisters uint16, data []byte) ([]byte, error)
+}
+
+// Session serializes modbus transactions against a single RTUContext
+// across concurrent callers, and retries failed transactions according
+// to a RetryPredicate with exponential backoff between attempts.
+// RTURead/RTUWrite on their own are one-shot, not safe to call
+// concurrently against a shared serial bus, and not cancellable; Session
+// exists to make that safe for production use against flaky RS-485 buses.
+type Session struct {
+	rtu   rtuTransaction
+	retry RetryPredicate
+
+	mu sync.Mutex
+}
+
+// NewSession wraps rtu in a Session that retries failed transactions
+// according to retry. A nil retry never retries, so Do behaves like a
+// single RTURead/RTUWrite call (but still serialized and cancellable).
+func NewSession(rtu *RTUContext, retry RetryPredicate) *Session {
+	return &Session{rtu: rtu, retry: retry}
+}
+
+// Do runs req against the session's RTUContext, serialized against any
+// other concurrent Do call on the same Session, retrying transient
+// failures (CRC mismatch, timeout, bad-unit-id discard) per the
+// session's RetryPredicate with exponential backoff between attempts.
+// It aborts and returns ctx.Err() as soon as ctx is done, whether that
+// happens while waiting for the bus, waiting on the slave device, or
+// waiting out a backoff.
+func (s *Session) Do(ctx context.Context, req Request) (Response, error) {
+	if err := ctx.Err(); err != nil {
+		return Response{}, err
+	}
+
+	// wait for the bus, giving up if ctx is done first; the lock is then
+	// released as soon as it is eventually acquired
+	locked := make(chan struct{})
+	go func() {
+		s.mu.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		go func() {
+			<-locked
+			s.mu.Unlock()
+		}()
+		return Response{}, ctx.Err()
+	}
+
+	var attempt int
+	backoff := sessionMinBackoff
+
+	for {
+		data, err, abandoned := s.doOnce(ctx, req)
+		if abandoned {
+			// ctx was cancelled while RTURead/RTUWrite was still in
+			// flight against the bus. doOnce has left a goroutine
+			// running that goroutine will release s.mu itself once
+			// that transaction actually finishes, so a concurrent Do
+			// on this Session can't start its own Read/Write while
+			// this one is still on the wire. Don't unlock here.
+			return Response{}, err
+		}
+		if err == nil {
+			s.mu.Unlock()
+			return Response{Data: data}, nil
+		}
+		if ctx.Err() != nil {
+			s.mu.Unlock()
+			return Response{}, ctx.Err()
+		}
+
+		attempt++
+		if s.retry == nil || !s.retry(attempt, err) {
+			s.mu.Unlock()
+			return Response{}, err
+		}
+
+		select {
+		case <-ctx.Done():
+			s.mu.Unlock()
+			return Response{}, ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > sessionMaxBackoff {
+			backoff = sessionMaxBackoff
+		}
+	}
+}
+
+const (
+	sessionMinBackoff = 25 * time.Millisecond
+	sessionMaxBackoff = 2 * time.Second
+)
+
+// doOnce runs req exactly once, racing the blocking RTURead/RTUWrite
+// call against ctx so a cancellation is noticed even while the session
+// is stuck waiting on the serial device. The underlying serial call has
+// no cancellation hook of its own, so when ctx wins the race, doOnce
+// reports abandoned=true and leaves a goroutine running in the
+// background to release s.mu once that call actually returns — the
+// caller (Do) must not unlock s.mu itself in that case, or a second Do
+// could start its own Read/Write on the same bus while this one is
+// still in flight.
+func (s *Session) doOnce(ctx context.Context, req Request) (data []byte, err error, abandoned bool) {
+	type result struct {
+		data []byte
+		err  error
+	}
+	done := make(chan result, 1)
+
+	go func() {
+		if ValidWriteFunction(req.FunctionCode) {
+			data, err := s.rtu.RTUWrite(req.FunctionCode, req.StartRegister, req.NumberOfRegisters, req.Data)
+			done <- result{data, err}
+			return
+		}
+		data, err := s.rtu.RTURead(req.FunctionCode, req.StartRegister, req.NumberOfRegisters)
+		done <- result{data, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		go func() {
+			<-done
+			s.mu.Unlock()
+		}()
+		return nil, ctx.Err(), true
+	case r := <-done:
+		return r.data, r.err, false
+	}
+}
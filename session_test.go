@@ -0,0 +1,144 @@
+package modbusclient
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeTransaction is an rtuTransaction whose RTURead/RTUWrite behavior is
+// driven by readFunc, for exercising Session without a real serial bus.
+type fakeTransaction struct {
+	readFunc func() ([]byte, error)
+}
+
+func (f *fakeTransaction) RTURead(functionCode byte, startRegister, numRegisters uint16) ([]byte, error) {
+	return f.readFunc()
+}
+
+func (f *fakeTransaction) RTUWrite(functionCode byte, startRegister, numRegisters uint16, data []byte) ([]byte, error) {
+	return f.readFunc()
+}
+
+func TestSessionDoSerializesConcurrentCalls(t *testing.T) {
+	var inFlight, overlapped int32
+	fake := &fakeTransaction{readFunc: func() ([]byte, error) {
+		if atomic.AddInt32(&inFlight, 1) > 1 {
+			atomic.StoreInt32(&overlapped, 1)
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+		return []byte{0x2a}, nil
+	}}
+	session := &Session{rtu: fake}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := session.Do(context.Background(), Request{FunctionCode: FUNCTION_READ_HOLDING_REGISTERS}); err != nil {
+				t.Errorf("Do() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if atomic.LoadInt32(&overlapped) != 0 {
+		t.Error("Do() let two underlying transactions run concurrently")
+	}
+}
+
+func TestSessionDoReturnsPromptlyWhenAbandoned(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	fake := &fakeTransaction{readFunc: func() ([]byte, error) {
+		started <- struct{}{}
+		<-release
+		return []byte{0x2a}, nil
+	}}
+	session := &Session{rtu: fake}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	doneCh := make(chan error, 1)
+	go func() {
+		_, err := session.Do(ctx, Request{FunctionCode: FUNCTION_READ_HOLDING_REGISTERS})
+		doneCh <- err
+	}()
+
+	<-started
+	cancel()
+
+	select {
+	case err := <-doneCh:
+		if err != context.Canceled {
+			t.Errorf("Do() error = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Do() did not return promptly after ctx was cancelled")
+	}
+
+	// The abandoned transaction is still in flight and still holds the
+	// session's mutex, so a second Do must block until it releases.
+	secondDone := make(chan error, 1)
+	go func() {
+		_, err := session.Do(context.Background(), Request{FunctionCode: FUNCTION_READ_HOLDING_REGISTERS})
+		secondDone <- err
+	}()
+
+	select {
+	case <-secondDone:
+		t.Fatal("second Do() ran before the abandoned transaction released the mutex")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+
+	select {
+	case err := <-secondDone:
+		if err != nil {
+			t.Errorf("second Do() error = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("second Do() did not complete after the mutex was released")
+	}
+}
+
+func TestSessionDoRetriesAccordingToPredicate(t *testing.T) {
+	var calls int32
+	wantErr := errors.New("transient")
+	fake := &fakeTransaction{readFunc: func() ([]byte, error) {
+		if atomic.AddInt32(&calls, 1) <= 2 {
+			return nil, wantErr
+		}
+		return []byte{0x2a}, nil
+	}}
+
+	var gotAttempts []int
+	var gotErrs []error
+	retry := func(attempt int, err error) bool {
+		gotAttempts = append(gotAttempts, attempt)
+		gotErrs = append(gotErrs, err)
+		return attempt <= 2
+	}
+	session := &Session{rtu: fake, retry: retry}
+
+	resp, err := session.Do(context.Background(), Request{FunctionCode: FUNCTION_READ_HOLDING_REGISTERS})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if string(resp.Data) != string([]byte{0x2a}) {
+		t.Errorf("Do() data = %x, want 2a", resp.Data)
+	}
+	if len(gotAttempts) != 2 || gotAttempts[0] != 1 || gotAttempts[1] != 2 {
+		t.Errorf("retry called with attempts %v, want [1 2]", gotAttempts)
+	}
+	for _, e := range gotErrs {
+		if e != wantErr {
+			t.Errorf("retry called with err %v, want %v", e, wantErr)
+		}
+	}
+}